@@ -15,116 +15,155 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log/slog"
 	"os"
+	"path"
+	"sync"
 	"time"
-)
 
-type AuthenticationResponse struct {
-	// JWT authentication token.
-	Token string `json:"token"`
-}
+	"github.com/spjmurray/hub-scrub/policy"
+	"github.com/spjmurray/hub-scrub/reaper"
+	"github.com/spjmurray/hub-scrub/registry"
+)
 
-type Page struct {
-	// Number of resources in the full list.
-	Count int `json:"count"`
+// resolvePolicy builds the retention policy for repository, either from
+// the -config file if one is configured, or from the legacy -t age
+// threshold.
+func resolvePolicy(configPath, thresholdStr, repository string) (policy.Policy, error) {
+	if configPath != "" {
+		config, err := policy.Load(configPath)
+		if err != nil {
+			return policy.Policy{}, err
+		}
 
-	// Path to the next set of results.
-	Next string `json:"next"`
+		p, ok := config.Policy(repository)
+		if !ok {
+			return policy.Policy{}, fmt.Errorf("no policy configured for repository %q in %s", repository, configPath)
+		}
 
-	// Path the the previous set of results.
-	Previous string `json:"previous"`
+		return p, nil
+	}
 
-	// Set of results.
-	Results []interface{} `json:"results"`
-}
+	threshold, err := time.ParseDuration(thresholdStr)
+	if err != nil {
+		return policy.Policy{}, fmt.Errorf("unable to parse threshold: %w", err)
+	}
 
-type Time struct {
-	time.Time
+	return policy.Policy{
+		Delete: policy.DeleteRules{
+			OlderThan: policy.Duration{Duration: threshold},
+		},
+	}, nil
 }
 
-func (t *Time) UnmarshalJSON(data []byte) error {
-	var s string
-
-	if err := json.Unmarshal(data, &s); err != nil {
-		return err
+// repositories returns the repositories to scrub: just image if it was
+// set, otherwise every repository under namespace that survives the
+// include/exclude glob filters.
+func repositories(r registry.Registry, image, namespace, include, exclude string) ([]string, error) {
+	if image != "" {
+		return []string{image}, nil
 	}
 
-	tt, err := time.Parse(time.RFC3339Nano, s)
+	all, err := r.ListRepositories(namespace)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("unable to list repositories: %w", err)
 	}
 
-	t.Time = tt
+	repos := make([]string, 0, len(all))
 
-	return nil
-}
+	for _, repo := range all {
+		if include != "" {
+			if ok, _ := path.Match(include, repo); !ok {
+				continue
+			}
+		}
 
-type Tag struct {
-	// Name of the tag.
-	Name string `json:"name"`
+		if exclude != "" {
+			if ok, _ := path.Match(exclude, repo); ok {
+				continue
+			}
+		}
 
-	// When it was last updated, but we treat this as when it was created.
-	LastUpdated Time `json:"last_updated"`
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
 }
 
-type TagList []Tag
+// evaluateRepository lists and evaluates a single repository's tags
+// against its policy, returning one reaper.Item per tag.
+func evaluateRepository(r registry.Registry, configPath, thresholdStr, repo string, now time.Time) ([]reaper.Item, error) {
+	p, err := resolvePolicy(configPath, thresholdStr, repo)
+	if err != nil {
+		return nil, err
+	}
 
-func List(token, url string, results interface{}) error {
-	resultsUntyped := []interface{}{}
+	tags, err := r.ListTags(repo)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		if url == "" {
-			break
-		}
+	decisions, err := policy.Evaluate(tags, p, now)
+	if err != nil {
+		return nil, err
+	}
 
-		req, err := http.NewRequest(http.MethodGet, url, nil)
-		if err != nil {
-			return err
-		}
+	items := make([]reaper.Item, len(decisions))
+	for i, d := range decisions {
+		items[i] = reaper.Item{Repository: repo, Decision: d}
+	}
 
-		req.Header.Add("Content-Type", "application/json")
-		req.Header.Add("Authorization", fmt.Sprintf("JWT %s", token))
+	return items, nil
+}
 
-		client := http.Client{}
+// evaluateRepositories runs evaluateRepository across repos concurrently,
+// bounded by concurrency, logging and skipping any repository that fails
+// rather than aborting the whole run.
+func evaluateRepositories(r registry.Registry, configPath, thresholdStr string, repos []string, concurrency int, log *slog.Logger) []reaper.Item {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
 
-		rsp, err := client.Do(req)
-		if err != nil {
-			return err
-		}
+	jobs := make(chan string)
 
-		body, err := ioutil.ReadAll(rsp.Body)
-		if err != nil {
-			return err
-		}
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		items []reaper.Item
+	)
 
-		rsp.Body.Close()
+	now := time.Now()
 
-		page := &Page{}
-		if err := json.Unmarshal(body, page); err != nil {
-			return err
-		}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
 
-		resultsUntyped = append(resultsUntyped, page.Results...)
+		go func() {
+			defer wg.Done()
 
-		url = page.Next
-	}
+			for repo := range jobs {
+				repoItems, err := evaluateRepository(r, configPath, thresholdStr, repo, now)
+				if err != nil {
+					log.Error("skipping repository", "repo", repo, "error", err.Error())
+					continue
+				}
 
-	resultsRaw, err := json.Marshal(resultsUntyped)
-	if err != nil {
-		return err
+				mu.Lock()
+				items = append(items, repoItems...)
+				mu.Unlock()
+			}
+		}()
 	}
 
-	if err := json.Unmarshal(resultsRaw, results); err != nil {
-		return err
+	for _, repo := range repos {
+		jobs <- repo
 	}
 
-	return nil
+	close(jobs)
+	wg.Wait()
+
+	return items
 }
 
 func main() {
@@ -134,97 +173,94 @@ func main() {
 	// Docker password.
 	var password string
 
-	// Docker image to interrogate.
+	// Docker image to interrogate. If empty, every repository under
+	// -namespace is scrubbed.
 	var image string
 
-	// Threshold to delete after.
+	// Threshold to delete after, used when -config is not set.
 	var thresholdStr string
 
-	flag.StringVar(&username, "u", "", "Docker Hub user name")
-	flag.StringVar(&password, "p", "", "Docker Hub password")
-	flag.StringVar(&image, "i", "", "Docker image")
-	flag.StringVar(&thresholdStr, "t", "", "Tag age threshold")
-	flag.Parse()
+	// Registry backend to talk to.
+	var backend string
 
-	threshold, err := time.ParseDuration(thresholdStr)
-	if err != nil {
-		fmt.Println("unable to parse threshold:", err)
-		os.Exit(1)
-	}
+	// Host for the v2 backend, e.g. a Harbor or GHCR instance.
+	var host string
 
-	client := http.Client{}
+	// Path to a retention policy config file.
+	var configPath string
 
-	// Authenticate and get an API token.
-	data := map[string]string{
-		"username": username,
-		"password": password,
-	}
+	// Whether to only report what would be deleted.
+	var dryRun bool
 
-	body, err := json.Marshal(data)
-	if err != nil {
-		fmt.Println("unable to marshal authentication credentials")
-		os.Exit(1)
-	}
+	// Number of tags to delete concurrently.
+	var concurrency int
 
-	req, err := http.NewRequest(http.MethodPost, "https://hub.docker.com/v2/users/login/", bytes.NewBuffer(body))
-	if err != nil {
-		fmt.Println("unable to create authentication request:", err)
-		os.Exit(1)
-	}
+	// Maximum sustained delete requests per second.
+	var qps float64
+
+	// Burst size backing -qps.
+	var burst int
 
-	req.Header.Set("Content-Type", "application/json")
+	// Namespace to enumerate repositories under when -i is not set.
+	var namespace string
 
-	rsp, err := client.Do(req)
+	// Glob a repository name must match to be scrubbed.
+	var include string
+
+	// Glob that excludes a repository from being scrubbed.
+	var exclude string
+
+	flag.StringVar(&username, "u", "", "Registry user name")
+	flag.StringVar(&password, "p", "", "Registry password")
+	flag.StringVar(&image, "i", "", "Docker image; if unset, every repository under -namespace is scrubbed")
+	flag.StringVar(&thresholdStr, "t", "", "Tag age threshold, used when -config is not set")
+	flag.StringVar(&backend, "registry", string(registry.BackendHub), "Registry backend to use: hub, v2")
+	flag.StringVar(&host, "host", "", "Registry host, e.g. https://harbor.example.com (required for -registry=v2)")
+	flag.StringVar(&configPath, "config", "", "Path to a retention policy config file (YAML or JSON)")
+	flag.BoolVar(&dryRun, "dry-run", false, "Report what would be deleted without deleting anything")
+	flag.IntVar(&concurrency, "concurrency", 4, "Number of tags, or repositories when -i is unset, to process concurrently")
+	flag.Float64Var(&qps, "qps", 0, "Maximum sustained delete requests per second, 0 for unlimited")
+	flag.IntVar(&burst, "burst", 1, "Burst size backing -qps")
+	flag.StringVar(&namespace, "namespace", "", "Hub account/organization to enumerate repositories under when -i is unset")
+	flag.StringVar(&include, "include", "", "Only scrub repositories matching this glob, e.g. 'myorg/*-ci'")
+	flag.StringVar(&exclude, "exclude", "", "Never scrub repositories matching this glob, e.g. 'myorg/prod-*'")
+	flag.Parse()
+
+	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	r, err := registry.New(registry.Backend(backend), host, username, password)
 	if err != nil {
-		fmt.Println("unable to authenticate with registry:", err)
+		log.Error("unable to create registry client", "error", err.Error())
 		os.Exit(1)
 	}
 
-	body, err = ioutil.ReadAll(rsp.Body)
+	repos, err := repositories(r, image, namespace, include, exclude)
 	if err != nil {
-		fmt.Println("unable to read authentication response:", err)
+		log.Error("unable to resolve repositories", "error", err.Error())
 		os.Exit(1)
 	}
 
-	rsp.Body.Close()
+	items := evaluateRepositories(r, configPath, thresholdStr, repos, concurrency, log)
 
-	authenticationResponse := &AuthenticationResponse{}
-	if err := json.Unmarshal(body, authenticationResponse); err != nil {
-		fmt.Println("unable to unmarshal authentication response:", err)
-		os.Exit(1)
-	}
-
-	// Get a list of tags for the requested image.
-	tags := TagList{}
-	if err := List(authenticationResponse.Token, fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/%s/tags", username, image), &tags); err != nil {
-		fmt.Println("unable to list tags:", err)
-		os.Exit(0)
-	}
+	if dryRun {
+		for _, item := range items {
+			if item.Decision.Action != policy.ActionDelete {
+				continue
+			}
 
-	// Reap old tags...
-	for _, tag := range tags {
-		if time.Since(tag.LastUpdated.Time) > threshold {
-			fmt.Println("deleting tag", tag.Name, "age", time.Since(tag.LastUpdated.Time))
+			log.Info("would delete tag", "repo", item.Repository, "tag", item.Decision.Tag.Name, "reason", item.Decision.Reason)
+		}
 
-			req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/%s/tags/%s/", username, image, tag.Name), nil)
-			if err != nil {
-				fmt.Println("unable to create delete request:", err)
-				os.Exit(1)
-			}
+		return
+	}
 
-			req.Header.Add("Authorization", fmt.Sprintf("JWT %s", authenticationResponse.Token))
+	reap := reaper.New(r, reaper.Config{Concurrency: concurrency, QPS: qps, Burst: burst}, log)
 
-			rsp, err := client.Do(req)
-			if err != nil {
-				fmt.Println("unable to perform delete request:", err)
-				os.Exit(1)
-			}
+	summary := reap.Reap(items)
 
-			rsp.Body.Close()
+	log.Info("reap complete", "repos", len(repos), "deleted", summary.Deleted, "kept", summary.Kept, "errored", summary.Errored)
 
-			if rsp.StatusCode != http.StatusNoContent {
-				fmt.Println("unexpected status code", rsp.StatusCode)
-			}
-		}
+	if summary.Errored > 0 {
+		os.Exit(1)
 	}
 }
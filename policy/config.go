@@ -0,0 +1,86 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy decides which tags a repository may keep and which are
+// candidates for deletion, replacing the tool's original "delete anything
+// older than duration" rule with a small set of composable rules.
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeepRules describes the tags within a repository that must never be
+// deleted, regardless of any DeleteRules. Keep rules always take
+// precedence over delete rules.
+type KeepRules struct {
+	// Latest keeps the N most recently updated tags.
+	Latest int `yaml:"latest"`
+
+	// Patterns keeps any tag whose name matches one of these regular
+	// expressions, e.g. `^v\d+\.\d+\.\d+$`, `^release-`, `^latest$`.
+	Patterns []string `yaml:"patterns"`
+
+	// MinorVersions keeps the most recently updated tag for each distinct
+	// major.minor version found amongst semver-like tag names.
+	MinorVersions bool `yaml:"minorVersions"`
+}
+
+// DeleteRules describes the tags within a repository that are candidates
+// for deletion. A delete rule only takes effect once none of a
+// repository's KeepRules have kept a given tag.
+type DeleteRules struct {
+	// OlderThan deletes any remaining tag last updated more than this
+	// long ago.
+	OlderThan Duration `yaml:"olderThan"`
+}
+
+// Policy is the full set of retention rules for a single repository.
+type Policy struct {
+	Keep   KeepRules   `yaml:"keep"`
+	Delete DeleteRules `yaml:"delete"`
+}
+
+// Config is the top level policy configuration file, keyed by repository
+// name, e.g. "myorg/myimage".
+type Config struct {
+	Repositories map[string]Policy `yaml:"repositories"`
+}
+
+// Load reads and parses a policy configuration file. YAML and JSON are
+// both accepted, since JSON is a valid subset of YAML.
+func Load(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read policy config %s: %w", path, err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse policy config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Policy returns the policy configured for repository, or ok=false if none
+// is configured.
+func (c *Config) Policy(repository string) (Policy, bool) {
+	p, ok := c.Repositories[repository]
+
+	return p, ok
+}
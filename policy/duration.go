@@ -0,0 +1,46 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration wraps time.Duration so policy config can express ages as plain
+// strings, e.g. "720h", the same as the -t flag.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	dd, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("unable to parse duration %q: %w", s, err)
+	}
+
+	d.Duration = dd
+
+	return nil
+}
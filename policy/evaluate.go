@@ -0,0 +1,158 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/spjmurray/hub-scrub/registry"
+)
+
+// Action is the outcome of evaluating a single tag against a Policy.
+type Action string
+
+const (
+	// ActionKeep means the tag must not be deleted.
+	ActionKeep Action = "keep"
+
+	// ActionDelete means the tag is a candidate for deletion.
+	ActionDelete Action = "delete"
+)
+
+// Decision records what should happen to a tag and which rule decided it,
+// so -dry-run output and logs can explain themselves.
+type Decision struct {
+	Tag    registry.Tag
+	Action Action
+	Reason string
+}
+
+// semverPattern extracts the major.minor version from tag names like
+// "v1.2.3" or "1.2".
+var semverPattern = regexp.MustCompile(`^v?(\d+\.\d+)`)
+
+// Evaluate applies p to the tags of a single repository and returns a
+// Decision for every tag, in no particular order. Keep rules are
+// evaluated first and always win: a tag is only a delete candidate once
+// none of them have claimed it. delete.olderThan never fires for a tag
+// whose LastUpdated is the zero value, since that means the backend
+// doesn't know the tag's age rather than that it is infinitely old.
+func Evaluate(tags []registry.Tag, p Policy, now time.Time) ([]Decision, error) {
+	patterns := make([]*regexp.Regexp, len(p.Keep.Patterns))
+
+	for i, pattern := range p.Keep.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep pattern %q: %w", pattern, err)
+		}
+
+		patterns[i] = re
+	}
+
+	keep := map[string]string{}
+
+	for _, tag := range latestN(tags, p.Keep.Latest) {
+		keep[tag.Name] = fmt.Sprintf("keep.latest (top %d most recent)", p.Keep.Latest)
+	}
+
+	for _, tag := range tags {
+		for i, re := range patterns {
+			if re.MatchString(tag.Name) {
+				keep[tag.Name] = fmt.Sprintf("keep.patterns[%d] (%q)", i, p.Keep.Patterns[i])
+				break
+			}
+		}
+	}
+
+	if p.Keep.MinorVersions {
+		for name, reason := range minorVersionKeepers(tags) {
+			keep[name] = reason
+		}
+	}
+
+	decisions := make([]Decision, 0, len(tags))
+
+	for _, tag := range tags {
+		if reason, ok := keep[tag.Name]; ok {
+			decisions = append(decisions, Decision{Tag: tag, Action: ActionKeep, Reason: reason})
+			continue
+		}
+
+		if age := now.Sub(tag.LastUpdated.Time); p.Delete.OlderThan.Duration > 0 && !tag.LastUpdated.Time.IsZero() && age > p.Delete.OlderThan.Duration {
+			decisions = append(decisions, Decision{
+				Tag:    tag,
+				Action: ActionDelete,
+				Reason: fmt.Sprintf("delete.olderThan %s (age %s)", p.Delete.OlderThan.Duration, age),
+			})
+
+			continue
+		}
+
+		decisions = append(decisions, Decision{Tag: tag, Action: ActionKeep, Reason: "no delete rule matched"})
+	}
+
+	return decisions, nil
+}
+
+// latestN returns the n most recently updated tags.
+func latestN(tags []registry.Tag, n int) []registry.Tag {
+	if n <= 0 {
+		return nil
+	}
+
+	sorted := make([]registry.Tag, len(tags))
+	copy(sorted, tags)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].LastUpdated.Time.After(sorted[j].LastUpdated.Time)
+	})
+
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+
+	return sorted[:n]
+}
+
+// minorVersionKeepers returns, for every distinct major.minor version
+// found amongst tags, the name of the most recently updated tag in that
+// group and why it was kept.
+func minorVersionKeepers(tags []registry.Tag) map[string]string {
+	latest := map[string]registry.Tag{}
+
+	for _, tag := range tags {
+		match := semverPattern.FindStringSubmatch(tag.Name)
+		if match == nil {
+			continue
+		}
+
+		version := match[1]
+
+		if current, ok := latest[version]; !ok || tag.LastUpdated.Time.After(current.LastUpdated.Time) {
+			latest[version] = tag
+		}
+	}
+
+	keepers := make(map[string]string, len(latest))
+
+	for version, tag := range latest {
+		keepers[tag.Name] = fmt.Sprintf("keep.minorVersions (latest for %s)", version)
+	}
+
+	return keepers
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spjmurray/hub-scrub/registry"
+)
+
+// at builds a registry.Tag with a LastUpdated offset from a fixed epoch by
+// daysAgo days, so test cases can express ages without depending on the
+// wall clock.
+func at(name string, daysAgo int) registry.Tag {
+	epoch := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return registry.Tag{
+		Name:        name,
+		LastUpdated: registry.Time{Time: epoch.Add(-time.Duration(daysAgo) * 24 * time.Hour)},
+	}
+}
+
+// decision looks up the Decision made for name, failing the test if none
+// was returned.
+func decision(t *testing.T, decisions []Decision, name string) Decision {
+	t.Helper()
+
+	for _, d := range decisions {
+		if d.Tag.Name == name {
+			return d
+		}
+	}
+
+	t.Fatalf("no decision returned for tag %q", name)
+
+	return Decision{}
+}
+
+func TestEvaluateOlderThanDeletesOldTags(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tags := []registry.Tag{at("old", 60), at("new", 1)}
+
+	p := Policy{Delete: DeleteRules{OlderThan: Duration{Duration: 30 * 24 * time.Hour}}}
+
+	decisions, err := Evaluate(tags, p, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decision(t, decisions, "old").Action != ActionDelete {
+		t.Errorf("expected old tag to be deleted")
+	}
+
+	if decision(t, decisions, "new").Action != ActionKeep {
+		t.Errorf("expected new tag to be kept")
+	}
+}
+
+// TestEvaluateOlderThanIgnoresZeroLastUpdated guards against a backend
+// (e.g. the Distribution/v2 client, which has no source of tag timestamps)
+// leaving LastUpdated at its zero value: time.Time.Sub on a zero value
+// clamps to the maximum duration, which must not be treated as "infinitely
+// old" or every tag from such a backend would be deleted.
+func TestEvaluateOlderThanIgnoresZeroLastUpdated(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tags := []registry.Tag{{Name: "unknown-age"}}
+
+	p := Policy{Delete: DeleteRules{OlderThan: Duration{Duration: time.Hour}}}
+
+	decisions, err := Evaluate(tags, p, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := decision(t, decisions, "unknown-age").Action; got != ActionKeep {
+		t.Errorf("expected tag with unknown age to be kept, got %s", got)
+	}
+}
+
+func TestEvaluateKeepPrecedenceOverDelete(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tags := []registry.Tag{at("latest", 400), at("release-1.0", 400), at("stale", 400)}
+
+	p := Policy{
+		Keep:   KeepRules{Patterns: []string{"^latest$", "^release-"}},
+		Delete: DeleteRules{OlderThan: Duration{Duration: 30 * 24 * time.Hour}},
+	}
+
+	decisions, err := Evaluate(tags, p, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decision(t, decisions, "latest").Action != ActionKeep {
+		t.Errorf("expected latest to be kept despite matching delete.olderThan")
+	}
+
+	if decision(t, decisions, "release-1.0").Action != ActionKeep {
+		t.Errorf("expected release-1.0 to be kept despite matching delete.olderThan")
+	}
+
+	if decision(t, decisions, "stale").Action != ActionDelete {
+		t.Errorf("expected stale to be deleted")
+	}
+}
+
+func TestEvaluateKeepLatest(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tags := []registry.Tag{at("newest", 1), at("middle", 2), at("oldest", 3)}
+
+	p := Policy{
+		Keep:   KeepRules{Latest: 2},
+		Delete: DeleteRules{OlderThan: Duration{Duration: time.Hour}},
+	}
+
+	decisions, err := Evaluate(tags, p, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decision(t, decisions, "newest").Action != ActionKeep {
+		t.Errorf("expected newest to be kept by keep.latest")
+	}
+
+	if decision(t, decisions, "middle").Action != ActionKeep {
+		t.Errorf("expected middle to be kept by keep.latest")
+	}
+
+	if decision(t, decisions, "oldest").Action != ActionDelete {
+		t.Errorf("expected oldest to fall outside keep.latest and be deleted")
+	}
+}
+
+func TestLatestN(t *testing.T) {
+	tags := []registry.Tag{at("a", 3), at("b", 1), at("c", 2)}
+
+	top := latestN(tags, 2)
+
+	if len(top) != 2 {
+		t.Fatalf("expected 2 tags, got %d", len(top))
+	}
+
+	if top[0].Name != "b" || top[1].Name != "c" {
+		t.Errorf("expected [b c] ordered by recency, got [%s %s]", top[0].Name, top[1].Name)
+	}
+}
+
+// TestLatestNTieBreak documents that latestN's sort is not stable: tags
+// sharing the same LastUpdated may come back in either relative order.
+// Callers must not depend on which of a tied pair is returned, only on how
+// many are.
+func TestLatestNTieBreak(t *testing.T) {
+	tied := at("one", 1)
+	tied.Name = "two"
+
+	tags := []registry.Tag{at("one", 1), tied}
+
+	top := latestN(tags, 1)
+
+	if len(top) != 1 {
+		t.Fatalf("expected exactly 1 tag, got %d", len(top))
+	}
+
+	if top[0].Name != "one" && top[0].Name != "two" {
+		t.Errorf("expected one of the tied tags, got %s", top[0].Name)
+	}
+}
+
+func TestLatestNZeroOrFewerReturnsNone(t *testing.T) {
+	tags := []registry.Tag{at("a", 1)}
+
+	if got := latestN(tags, 0); got != nil {
+		t.Errorf("expected nil for n=0, got %v", got)
+	}
+
+	if got := latestN(tags, -1); got != nil {
+		t.Errorf("expected nil for n<0, got %v", got)
+	}
+}
+
+func TestMinorVersionKeepers(t *testing.T) {
+	tags := []registry.Tag{
+		at("v1.2.0", 10),
+		at("v1.2.1", 1),
+		at("v1.3.0", 5),
+		at("not-a-version", 1),
+	}
+
+	keepers := minorVersionKeepers(tags)
+
+	if _, ok := keepers["v1.2.1"]; !ok {
+		t.Errorf("expected v1.2.1 to be kept as the latest of the 1.2 line")
+	}
+
+	if _, ok := keepers["v1.2.0"]; ok {
+		t.Errorf("did not expect v1.2.0 to be kept, v1.2.1 is newer within the same minor version")
+	}
+
+	if _, ok := keepers["v1.3.0"]; !ok {
+		t.Errorf("expected v1.3.0 to be kept as the only tag in the 1.3 line")
+	}
+
+	if len(keepers) != 2 {
+		t.Errorf("expected exactly 2 keepers, got %d: %v", len(keepers), keepers)
+	}
+}
+
+func TestEvaluateInvalidKeepPattern(t *testing.T) {
+	p := Policy{Keep: KeepRules{Patterns: []string{"("}}}
+
+	if _, err := Evaluate(nil, p, time.Now()); err == nil {
+		t.Errorf("expected an error for an invalid keep pattern")
+	}
+}
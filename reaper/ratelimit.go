@@ -0,0 +1,88 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reaper
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter shared across the reaper's
+// worker pool, so the combined rate of requests against the registry
+// stays within -qps, bursting up to -burst.
+//
+// A nil *RateLimiter is valid and imposes no limit, so callers that don't
+// configure a QPS can skip constructing one.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	qps   float64
+	burst float64
+
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing qps requests per second,
+// bursting up to burst. It returns nil, meaning unlimited, if qps is not
+// positive.
+func NewRateLimiter(qps float64, burst int) *RateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available.
+func (r *RateLimiter) Wait() {
+	if r == nil {
+		return
+	}
+
+	for {
+		r.mu.Lock()
+
+		now := time.Now()
+		r.tokens += now.Sub(r.last).Seconds() * r.qps
+
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+
+		r.last = now
+
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+
+			return
+		}
+
+		wait := time.Duration((1 - r.tokens) / r.qps * float64(time.Second))
+
+		r.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
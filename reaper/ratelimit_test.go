@@ -0,0 +1,81 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reaper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiterUnlimitedWhenQPSNotPositive(t *testing.T) {
+	if l := NewRateLimiter(0, 10); l != nil {
+		t.Errorf("expected a nil limiter for qps <= 0, got %v", l)
+	}
+
+	if l := NewRateLimiter(-1, 10); l != nil {
+		t.Errorf("expected a nil limiter for qps <= 0, got %v", l)
+	}
+}
+
+func TestNilRateLimiterWaitIsNoOp(t *testing.T) {
+	var l *RateLimiter
+
+	done := make(chan struct{})
+
+	go func() {
+		l.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Wait on a nil limiter to return immediately")
+	}
+}
+
+func TestRateLimiterAllowsBurstThenThrottles(t *testing.T) {
+	l := NewRateLimiter(10, 2)
+
+	start := time.Now()
+
+	// The burst of 2 should be consumed immediately.
+	l.Wait()
+	l.Wait()
+
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected the burst to be consumed without waiting, took %s", elapsed)
+	}
+
+	// The third request exceeds the burst and must wait for a token to
+	// refill at 10 qps, i.e. roughly 100ms.
+	l.Wait()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected the third request to be throttled, took %s", elapsed)
+	}
+}
+
+func TestNewRateLimiterDefaultsNonPositiveBurstToOne(t *testing.T) {
+	l := NewRateLimiter(10, 0)
+
+	if l == nil {
+		t.Fatal("expected a non-nil limiter")
+	}
+
+	if l.burst != 1 {
+		t.Errorf("expected burst to default to 1, got %v", l.burst)
+	}
+}
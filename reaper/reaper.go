@@ -0,0 +1,205 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reaper drives the actual deletion of tags a policy has
+// condemned: fanning DELETEs out over a bounded worker pool, keeping
+// within a configured rate limit, and retrying transient failures with
+// backoff.
+package reaper
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/spjmurray/hub-scrub/policy"
+	"github.com/spjmurray/hub-scrub/registry"
+)
+
+// maxAttempts bounds the number of attempts, the first included, made at
+// a single delete before it is counted as an error.
+const maxAttempts = 5
+
+// initialBackoff is the delay before the first retry, doubling on each
+// subsequent attempt, when the registry does not send a Retry-After.
+const initialBackoff = time.Second
+
+// Config controls how a Reaper fans deletes out.
+type Config struct {
+	// Concurrency is the number of workers deleting tags in parallel.
+	Concurrency int
+
+	// QPS caps the combined rate of delete requests across all workers.
+	// Zero means unlimited.
+	QPS float64
+
+	// Burst is the token bucket burst size backing QPS.
+	Burst int
+}
+
+// Summary aggregates the outcome of a Reap call.
+type Summary struct {
+	Deleted int
+	Kept    int
+	Errored int
+}
+
+// Item pairs a policy decision with the repository it was computed
+// against, so a single Reap call can fan work out across many
+// repositories while sharing one worker pool and rate limiter.
+type Item struct {
+	Repository string
+	Decision   policy.Decision
+}
+
+// Reaper deletes the tags a policy has condemned to deletion, across a
+// bounded pool of goroutines, subject to a shared rate limit.
+type Reaper struct {
+	registry registry.Registry
+	limiter  *RateLimiter
+	config   Config
+	log      *slog.Logger
+}
+
+// New creates a Reaper. log receives one record per tag reaped, plus
+// warnings for retried deletes.
+func New(r registry.Registry, config Config, log *slog.Logger) *Reaper {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+
+	return &Reaper{
+		registry: r,
+		limiter:  NewRateLimiter(config.QPS, config.Burst),
+		config:   config,
+		log:      log,
+	}
+}
+
+// Reap deletes every item in items whose Decision.Action is
+// policy.ActionDelete, fanning the work out across the configured worker
+// pool and shared rate limiter, and returns a Summary of the outcome.
+// Items may span multiple repositories.
+func (r *Reaper) Reap(items []Item) Summary {
+	summary := Summary{}
+
+	toDelete := make([]Item, 0, len(items))
+
+	for _, item := range items {
+		if item.Decision.Action != policy.ActionDelete {
+			summary.Kept++
+
+			r.log.Info("kept tag", "repo", item.Repository, "tag", item.Decision.Tag.Name, "reason", item.Decision.Reason)
+
+			continue
+		}
+
+		toDelete = append(toDelete, item)
+	}
+
+	jobs := make(chan Item)
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for i := 0; i < r.config.Concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for item := range jobs {
+				ok := r.delete(item)
+
+				mu.Lock()
+
+				if ok {
+					summary.Deleted++
+				} else {
+					summary.Errored++
+				}
+
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, item := range toDelete {
+		jobs <- item
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return summary
+}
+
+// delete removes a single tag, retrying on transient failures with
+// exponential backoff, honoring any Retry-After the registry sends.
+func (r *Reaper) delete(item Item) bool {
+	repository := item.Repository
+	tag := item.Decision.Tag.Name
+	age := time.Since(item.Decision.Tag.LastUpdated.Time)
+	backoff := initialBackoff
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		r.limiter.Wait()
+
+		err := r.registry.DeleteTag(repository, tag)
+		if err == nil {
+			r.log.Info("deleted tag", "repo", repository, "tag", tag, "age", age.String(), "outcome", "deleted", "http_status", http.StatusNoContent)
+
+			return true
+		}
+
+		statusErr := &registry.StatusError{}
+
+		retryable := errors.As(err, &statusErr) && isRetryable(statusErr.StatusCode)
+		if retryable && attempt < maxAttempts {
+			wait := backoff
+			if statusErr.RetryAfter > 0 {
+				wait = statusErr.RetryAfter
+			}
+
+			r.log.Warn("retrying tag delete", "repo", repository, "tag", tag, "attempt", attempt, "http_status", statusErr.StatusCode, "wait", wait.String())
+
+			time.Sleep(wait)
+
+			backoff *= 2
+
+			continue
+		}
+
+		status := 0
+		if errors.As(err, &statusErr) {
+			status = statusErr.StatusCode
+		}
+
+		r.log.Error("failed to delete tag", "repo", repository, "tag", tag, "age", age.String(), "outcome", "error", "http_status", status, "error", err.Error())
+
+		return false
+	}
+
+	return false
+}
+
+// isRetryable reports whether an HTTP status code indicates a transient
+// failure worth retrying.
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reaper
+
+import (
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spjmurray/hub-scrub/policy"
+	"github.com/spjmurray/hub-scrub/registry"
+)
+
+// discardLogger is a *slog.Logger that throws its records away, so tests
+// don't spam output.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(nopWriter{}, nil))
+}
+
+type nopWriter struct{}
+
+func (nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+// fakeRegistry is a registry.Registry stub whose DeleteTag behaviour is
+// scripted by a callback, so reaper tests can drive retry and failure
+// paths without a real registry.
+type fakeRegistry struct {
+	deleteFunc func(repository, tag string) error
+}
+
+func (f *fakeRegistry) ListRepositories(string) ([]string, error)  { return nil, nil }
+func (f *fakeRegistry) ListTags(string) ([]registry.Tag, error)    { return nil, nil }
+func (f *fakeRegistry) GetManifest(string, string) (string, error) { return "", nil }
+
+func (f *fakeRegistry) DeleteTag(repository, tag string) error {
+	return f.deleteFunc(repository, tag)
+}
+
+func item(tag string, action policy.Action) Item {
+	return Item{
+		Repository: "library/nginx",
+		Decision:   policy.Decision{Tag: registry.Tag{Name: tag}, Action: action},
+	}
+}
+
+func TestReapCountsKeptAndDeleted(t *testing.T) {
+	r := &fakeRegistry{deleteFunc: func(string, string) error { return nil }}
+
+	reaper := New(r, Config{Concurrency: 2}, discardLogger())
+
+	summary := reaper.Reap([]Item{
+		item("latest", policy.ActionKeep),
+		item("old-1", policy.ActionDelete),
+		item("old-2", policy.ActionDelete),
+	})
+
+	if summary.Kept != 1 {
+		t.Errorf("expected 1 kept, got %d", summary.Kept)
+	}
+
+	if summary.Deleted != 2 {
+		t.Errorf("expected 2 deleted, got %d", summary.Deleted)
+	}
+
+	if summary.Errored != 0 {
+		t.Errorf("expected 0 errored, got %d", summary.Errored)
+	}
+}
+
+func TestReapRetriesRetryableStatusThenSucceeds(t *testing.T) {
+	var attempts int32
+
+	r := &fakeRegistry{
+		deleteFunc: func(string, string) error {
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				return &registry.StatusError{StatusCode: 429, RetryAfter: time.Millisecond, Err: fmt.Errorf("rate limited")}
+			}
+
+			return nil
+		},
+	}
+
+	reaper := New(r, Config{Concurrency: 1}, discardLogger())
+
+	summary := reaper.Reap([]Item{item("flaky", policy.ActionDelete)})
+
+	if summary.Deleted != 1 {
+		t.Errorf("expected the delete to eventually succeed, got summary %+v", summary)
+	}
+
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestReapGivesUpAfterMaxAttempts(t *testing.T) {
+	var attempts int32
+
+	r := &fakeRegistry{
+		deleteFunc: func(string, string) error {
+			atomic.AddInt32(&attempts, 1)
+
+			return &registry.StatusError{StatusCode: 503, RetryAfter: time.Millisecond, Err: fmt.Errorf("unavailable")}
+		},
+	}
+
+	reaper := New(r, Config{Concurrency: 1}, discardLogger())
+
+	summary := reaper.Reap([]Item{item("persistently-broken", policy.ActionDelete)})
+
+	if summary.Errored != 1 {
+		t.Errorf("expected the delete to be counted as an error, got summary %+v", summary)
+	}
+
+	if attempts != maxAttempts {
+		t.Errorf("expected %d attempts, got %d", maxAttempts, attempts)
+	}
+}
+
+func TestReapDoesNotRetryNonRetryableStatus(t *testing.T) {
+	var attempts int32
+
+	r := &fakeRegistry{
+		deleteFunc: func(string, string) error {
+			atomic.AddInt32(&attempts, 1)
+
+			return &registry.StatusError{StatusCode: 404, Err: fmt.Errorf("not found")}
+		},
+	}
+
+	reaper := New(r, Config{Concurrency: 1}, discardLogger())
+
+	summary := reaper.Reap([]Item{item("missing", policy.ActionDelete)})
+
+	if summary.Errored != 1 {
+		t.Errorf("expected the delete to be counted as an error, got summary %+v", summary)
+	}
+
+	if attempts != 1 {
+		t.Errorf("expected no retries for a non-retryable status, got %d attempts", attempts)
+	}
+}
+
+func TestNewClampsNonPositiveConcurrency(t *testing.T) {
+	r := &fakeRegistry{deleteFunc: func(string, string) error { return nil }}
+
+	reaper := New(r, Config{Concurrency: 0}, discardLogger())
+
+	if reaper.config.Concurrency != 1 {
+		t.Errorf("expected concurrency to be clamped to 1, got %d", reaper.config.Concurrency)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := map[int]bool{
+		429: true,
+		500: true,
+		503: true,
+		404: false,
+		200: false,
+	}
+
+	for status, want := range cases {
+		if got := isRetryable(status); got != want {
+			t.Errorf("isRetryable(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth implements the challenge/response authentication flow used
+// by the Docker Registry HTTP API V2 / OCI Distribution Specification,
+// modelled on the authorizer in the upstream `distribution` project.
+//
+// A registry request that requires authentication is rejected with a 401
+// and a WWW-Authenticate header describing the scheme (e.g. Basic or
+// Bearer) and the parameters needed to satisfy it. An Authorizer parses
+// that challenge, dispatches it to a registered Handler, and retries the
+// request with the credentials the handler attaches.
+package auth
+
+import "net/http"
+
+// Handler authenticates a single request against a challenge advertised by
+// a registry via the WWW-Authenticate header.
+type Handler interface {
+	// Scheme returns the WWW-Authenticate scheme this handler satisfies,
+	// e.g. "Basic" or "Bearer". Matching is case insensitive.
+	Scheme() string
+
+	// AuthorizeRequest mutates req, typically by setting an Authorization
+	// header, so that it satisfies the challenge described by params.
+	AuthorizeRequest(req *http.Request, params map[string]string) error
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authorizer is an http.RoundTripper that transparently satisfies
+// WWW-Authenticate challenges, dispatching each one to a registered
+// Handler and retrying the request with the credentials it attaches.
+type Authorizer struct {
+	// Base is the underlying transport used to perform requests. Defaults
+	// to http.DefaultTransport.
+	Base http.RoundTripper
+
+	handlers map[string]Handler
+}
+
+// NewAuthorizer creates an Authorizer that can satisfy challenges for any
+// of the given handlers, keyed by their Scheme.
+func NewAuthorizer(handlers ...Handler) *Authorizer {
+	m := make(map[string]Handler, len(handlers))
+
+	for _, h := range handlers {
+		m[strings.ToLower(h.Scheme())] = h
+	}
+
+	return &Authorizer{handlers: m}
+}
+
+// base returns the configured transport, or http.DefaultTransport if unset.
+func (a *Authorizer) base() http.RoundTripper {
+	if a.Base != nil {
+		return a.Base
+	}
+
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (a *Authorizer) RoundTrip(req *http.Request) (*http.Response, error) {
+	rsp, err := a.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, nil
+	}
+
+	challenges := ParseChallenges(rsp.Header.Values("Www-Authenticate"))
+
+	for _, challenge := range challenges {
+		handler, ok := a.handlers[strings.ToLower(challenge.Scheme)]
+		if !ok {
+			continue
+		}
+
+		rsp.Body.Close()
+
+		retry := req.Clone(req.Context())
+
+		if err := handler.AuthorizeRequest(retry, challenge.Params); err != nil {
+			return nil, err
+		}
+
+		return a.base().RoundTrip(retry)
+	}
+
+	if len(challenges) == 0 {
+		return rsp, nil
+	}
+
+	rsp.Body.Close()
+
+	return nil, errNoHandler
+}
+
+// errNoHandler is returned when none of the challenges on a 401 response
+// match a registered handler.
+var errNoHandler = fmt.Errorf("no registered auth handler satisfies the registry's challenge")
@@ -0,0 +1,144 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripperFunc adapts a function to an http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newResponse(status int, header http.Header) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       http.NoBody,
+	}
+}
+
+func TestAuthorizerRetriesWithMatchingHandler(t *testing.T) {
+	var authorized bool
+
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Authorization") != "" {
+			authorized = true
+
+			return newResponse(http.StatusOK, nil), nil
+		}
+
+		header := http.Header{}
+		header.Set("Www-Authenticate", `Basic realm="registry"`)
+
+		return newResponse(http.StatusUnauthorized, header), nil
+	})
+
+	a := NewAuthorizer(NewBasicHandler("alice", "hunter2"))
+	a.Base = base
+
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rsp, err := a.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("expected a 200 after authorization, got %d", rsp.StatusCode)
+	}
+
+	if !authorized {
+		t.Errorf("expected the retried request to carry an Authorization header")
+	}
+}
+
+func TestAuthorizerPassesThroughNonChallengeResponses(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return newResponse(http.StatusOK, nil), nil
+	})
+
+	a := NewAuthorizer(NewBasicHandler("alice", "hunter2"))
+	a.Base = base
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	rsp, err := a.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("expected a 200, got %d", rsp.StatusCode)
+	}
+}
+
+func TestAuthorizerUnauthorizedWithoutChallengeIsReturnedAsIs(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return newResponse(http.StatusUnauthorized, nil), nil
+	})
+
+	a := NewAuthorizer(NewBasicHandler("alice", "hunter2"))
+	a.Base = base
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	rsp, err := a.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rsp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the 401 to be passed through, got %d", rsp.StatusCode)
+	}
+}
+
+func TestAuthorizerErrorsWithoutAMatchingHandler(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Www-Authenticate", `Bearer realm="https://auth.example.com/token"`)
+
+		return newResponse(http.StatusUnauthorized, header), nil
+	})
+
+	a := NewAuthorizer(NewBasicHandler("alice", "hunter2"))
+	a.Base = base
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	if _, err := a.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "no registered auth handler") {
+		t.Errorf("expected errNoHandler, got %v", err)
+	}
+}
+
+func TestAuthorizerDefaultsBaseToDefaultTransport(t *testing.T) {
+	a := NewAuthorizer()
+
+	if a.base() != http.DefaultTransport {
+		t.Errorf("expected base() to fall back to http.DefaultTransport")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import "net/http"
+
+// BasicHandler satisfies a "Basic" WWW-Authenticate challenge with a
+// static username and password.
+type BasicHandler struct {
+	username string
+	password string
+}
+
+// NewBasicHandler creates a Handler for HTTP Basic authentication.
+func NewBasicHandler(username, password string) *BasicHandler {
+	return &BasicHandler{
+		username: username,
+		password: password,
+	}
+}
+
+// Scheme implements Handler.
+func (h *BasicHandler) Scheme() string {
+	return "Basic"
+}
+
+// AuthorizeRequest implements Handler.
+func (h *BasicHandler) AuthorizeRequest(req *http.Request, _ map[string]string) error {
+	req.SetBasicAuth(h.username, h.password)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBasicHandlerAuthorizeRequest(t *testing.T) {
+	h := NewBasicHandler("alice", "hunter2")
+
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := h.AuthorizeRequest(req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		t.Fatalf("expected an Authorization header to be set")
+	}
+
+	if username != "alice" || password != "hunter2" {
+		t.Errorf("expected alice:hunter2, got %s:%s", username, password)
+	}
+}
+
+func TestBasicHandlerSchemeIsBasic(t *testing.T) {
+	if got := NewBasicHandler("", "").Scheme(); got != "Basic" {
+		t.Errorf("expected scheme Basic, got %s", got)
+	}
+}
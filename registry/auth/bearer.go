@@ -0,0 +1,185 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// tokenResponse is returned by a Bearer realm's token endpoint.
+type tokenResponse struct {
+	// Token is the preferred field name for the bearer token.
+	Token string `json:"token"`
+
+	// AccessToken is an alternative field name some registries use
+	// instead of Token.
+	AccessToken string `json:"access_token"`
+
+	// ExpiresIn is the token lifetime in seconds.
+	ExpiresIn int `json:"expires_in"`
+
+	// IssuedAt is when the token was minted. When set, the token's expiry
+	// is counted from it rather than from the local clock at fetch time;
+	// that expiry is then compared against the local clock.
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// defaultTokenLifetime is used when a token response omits expires_in, per
+// the Distribution Specification's recommended default.
+const defaultTokenLifetime = 60 * time.Second
+
+// cachedToken is a Bearer token along with when it stops being valid.
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// BearerHandler satisfies a "Bearer" WWW-Authenticate challenge by
+// fetching a token from the realm advertised in the challenge, caching it
+// per service+scope until it expires.
+type BearerHandler struct {
+	username string
+	password string
+	client   http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedToken
+}
+
+// NewBearerHandler creates a Handler for the Bearer token scheme. username
+// and password, if set, are used to authenticate against the token realm;
+// leave them empty for anonymous pull access.
+func NewBearerHandler(username, password string) *BearerHandler {
+	return &BearerHandler{
+		username: username,
+		password: password,
+		cache:    map[string]cachedToken{},
+	}
+}
+
+// Scheme implements Handler.
+func (h *BearerHandler) Scheme() string {
+	return "Bearer"
+}
+
+// AuthorizeRequest implements Handler.
+func (h *BearerHandler) AuthorizeRequest(req *http.Request, params map[string]string) error {
+	token, err := h.token(params["realm"], params["service"], params["scope"])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
+
+// token returns a cached token for service+scope if one is still valid,
+// otherwise it fetches and caches a fresh one.
+func (h *BearerHandler) token(realm, service, scope string) (string, error) {
+	key := service + " " + scope
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cached, ok := h.cache[key]; ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	cached, err := h.fetch(realm, service, scope)
+	if err != nil {
+		return "", err
+	}
+
+	h.cache[key] = cached
+
+	return cached.token, nil
+}
+
+// fetch requests a fresh token from realm.
+func (h *BearerHandler) fetch(realm, service, scope string) (cachedToken, error) {
+	u, err := url.Parse(realm)
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("unable to parse token realm %q: %w", realm, err)
+	}
+
+	q := u.Query()
+
+	if service != "" {
+		q.Set("service", service)
+	}
+
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	if h.username != "" {
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	rsp, err := h.client.Do(req)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return cachedToken{}, fmt.Errorf("token endpoint %s returned status %d", realm, rsp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	tr := tokenResponse{}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return cachedToken{}, fmt.Errorf("unable to unmarshal token response: %w", err)
+	}
+
+	token := tr.Token
+	if token == "" {
+		token = tr.AccessToken
+	}
+
+	issuedAt := tr.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+
+	lifetime := defaultTokenLifetime
+	if tr.ExpiresIn > 0 {
+		lifetime = time.Duration(tr.ExpiresIn) * time.Second
+	}
+
+	return cachedToken{
+		token:     token,
+		expiresAt: issuedAt.Add(lifetime),
+	}, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// tokenServer returns an httptest server that hands out a fresh token on
+// every request, counting how many times it was hit.
+func tokenServer(t *testing.T, expiresIn int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var hits int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		fmt.Fprintf(w, `{"token":"token-%d","expires_in":%d}`, n, expiresIn)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &hits
+}
+
+func TestBearerHandlerFetchesAndCachesToken(t *testing.T) {
+	srv, hits := tokenServer(t, 3600)
+
+	h := NewBearerHandler("", "")
+
+	req, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := h.AuthorizeRequest(req, map[string]string{"realm": srv.URL, "service": "registry.example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer token-1" {
+		t.Errorf("expected Authorization %q, got %q", "Bearer token-1", got)
+	}
+
+	// A second request for the same service+scope must reuse the cached
+	// token rather than hitting the realm again.
+	req2, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	if err := h.AuthorizeRequest(req2, map[string]string{"realm": srv.URL, "service": "registry.example.com"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := req2.Header.Get("Authorization"); got != "Bearer token-1" {
+		t.Errorf("expected cached token to be reused, got %q", got)
+	}
+
+	if *hits != 1 {
+		t.Errorf("expected the realm to be hit once, got %d", *hits)
+	}
+}
+
+func TestBearerHandlerRefetchesAfterExpiry(t *testing.T) {
+	// issued_at is far in the past, so the token is already expired by
+	// the time it's cached and the next AuthorizeRequest must fetch a
+	// fresh one rather than reuse it.
+	var hits int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+
+		fmt.Fprintf(w, `{"token":"token-%d","issued_at":"2000-01-01T00:00:00Z","expires_in":1}`, n)
+	}))
+	t.Cleanup(srv.Close)
+
+	h := NewBearerHandler("", "")
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+		if err := h.AuthorizeRequest(req, map[string]string{"realm": srv.URL, "service": "registry.example.com"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if hits != 2 {
+		t.Errorf("expected an expired token to be refetched, got %d hits", hits)
+	}
+}
+
+func TestBearerHandlerCachesPerScope(t *testing.T) {
+	srv, hits := tokenServer(t, 3600)
+
+	h := NewBearerHandler("", "")
+
+	pull, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+	if err := h.AuthorizeRequest(pull, map[string]string{"realm": srv.URL, "service": "registry.example.com", "scope": "repository:nginx:pull"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	push, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/manifests/latest", nil)
+	if err := h.AuthorizeRequest(push, map[string]string{"realm": srv.URL, "service": "registry.example.com", "scope": "repository:nginx:push"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *hits != 2 {
+		t.Errorf("expected distinct scopes to fetch independent tokens, got %d hits", *hits)
+	}
+
+	if pull.Header.Get("Authorization") == push.Header.Get("Authorization") {
+		t.Errorf("expected distinct tokens for distinct scopes")
+	}
+}
+
+func TestBearerHandlerUsesAccessTokenField(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"legacy-token","expires_in":3600}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	h := NewBearerHandler("", "")
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+	if err := h.AuthorizeRequest(req, map[string]string{"realm": srv.URL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := req.Header.Get("Authorization"); got != "Bearer legacy-token" {
+		t.Errorf("expected access_token field to be used, got %q", got)
+	}
+}
+
+func TestBearerHandlerErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	h := NewBearerHandler("", "")
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	if err := h.AuthorizeRequest(req, map[string]string{"realm": srv.URL}); err == nil {
+		t.Errorf("expected an error for a non-200 token response")
+	}
+}
+
+func TestBearerHandlerInvalidRealm(t *testing.T) {
+	h := NewBearerHandler("", "")
+
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/nginx/tags/list", nil)
+
+	if err := h.AuthorizeRequest(req, map[string]string{"realm": "://not-a-url"}); err == nil {
+		t.Errorf("expected an error for an unparseable realm")
+	}
+}
+
+func TestBearerHandlerSchemeIsBearer(t *testing.T) {
+	if got := NewBearerHandler("", "").Scheme(); got != "Bearer" {
+		t.Errorf("expected scheme Bearer, got %s", got)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Challenge is a single parsed WWW-Authenticate challenge, e.g.
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+type Challenge struct {
+	// Scheme is the authentication scheme being challenged for.
+	Scheme string
+
+	// Params holds the challenge's key/value parameters, e.g. "realm",
+	// "service" and "scope" for a Bearer challenge.
+	Params map[string]string
+}
+
+// challengeParamPattern matches key="value" pairs within a challenge.
+var challengeParamPattern = regexp.MustCompile(`([a-zA-Z0-9_]+)="([^"]*)"`)
+
+// ParseChallenges parses the value of every WWW-Authenticate header on a
+// response into a Challenge, one per header. Every header yields a
+// Challenge; parameters that are not of the form key="value" are dropped.
+func ParseChallenges(headers []string) []Challenge {
+	challenges := make([]Challenge, 0, len(headers))
+
+	for _, header := range headers {
+		if c, ok := parseChallenge(header); ok {
+			challenges = append(challenges, c)
+		}
+	}
+
+	return challenges
+}
+
+// parseChallenge parses a single WWW-Authenticate header value.
+func parseChallenge(header string) (Challenge, bool) {
+	parts := strings.SplitN(header, " ", 2)
+
+	params := map[string]string{}
+
+	if len(parts) == 2 {
+		for _, match := range challengeParamPattern.FindAllStringSubmatch(parts[1], -1) {
+			params[match[1]] = match[2]
+		}
+	}
+
+	return Challenge{Scheme: parts[0], Params: params}, true
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import "testing"
+
+func TestParseChallengesBearer(t *testing.T) {
+	headers := []string{`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`}
+
+	challenges := ParseChallenges(headers)
+
+	if len(challenges) != 1 {
+		t.Fatalf("expected 1 challenge, got %d", len(challenges))
+	}
+
+	c := challenges[0]
+
+	if c.Scheme != "Bearer" {
+		t.Errorf("expected scheme Bearer, got %s", c.Scheme)
+	}
+
+	if c.Params["realm"] != "https://auth.docker.io/token" {
+		t.Errorf("unexpected realm: %s", c.Params["realm"])
+	}
+
+	if c.Params["service"] != "registry.docker.io" {
+		t.Errorf("unexpected service: %s", c.Params["service"])
+	}
+
+	if c.Params["scope"] != "repository:library/nginx:pull" {
+		t.Errorf("unexpected scope: %s", c.Params["scope"])
+	}
+}
+
+func TestParseChallengesBasic(t *testing.T) {
+	challenges := ParseChallenges([]string{`Basic realm="registry"`})
+
+	if len(challenges) != 1 {
+		t.Fatalf("expected 1 challenge, got %d", len(challenges))
+	}
+
+	if challenges[0].Scheme != "Basic" {
+		t.Errorf("expected scheme Basic, got %s", challenges[0].Scheme)
+	}
+}
+
+func TestParseChallengesMultipleHeaders(t *testing.T) {
+	challenges := ParseChallenges([]string{
+		`Basic realm="registry"`,
+		`Bearer realm="https://auth.example.com/token",service="registry.example.com"`,
+	})
+
+	if len(challenges) != 2 {
+		t.Fatalf("expected 2 challenges, got %d", len(challenges))
+	}
+
+	if challenges[0].Scheme != "Basic" || challenges[1].Scheme != "Bearer" {
+		t.Errorf("expected [Basic Bearer], got [%s %s]", challenges[0].Scheme, challenges[1].Scheme)
+	}
+}
+
+func TestParseChallengesSchemeOnlyNoParams(t *testing.T) {
+	challenges := ParseChallenges([]string{"Negotiate"})
+
+	if len(challenges) != 1 {
+		t.Fatalf("expected 1 challenge, got %d", len(challenges))
+	}
+
+	if challenges[0].Scheme != "Negotiate" {
+		t.Errorf("expected scheme Negotiate, got %s", challenges[0].Scheme)
+	}
+
+	if len(challenges[0].Params) != 0 {
+		t.Errorf("expected no params, got %v", challenges[0].Params)
+	}
+}
+
+func TestParseChallengesEmpty(t *testing.T) {
+	if challenges := ParseChallenges(nil); len(challenges) != 0 {
+		t.Errorf("expected no challenges, got %v", challenges)
+	}
+}
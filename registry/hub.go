@@ -0,0 +1,315 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// hubBaseURL is the root of the Docker Hub specific (non-distribution) API.
+const hubBaseURL = "https://hub.docker.com/v2"
+
+// hubAuthenticationResponse is returned by the Hub login endpoint.
+type hubAuthenticationResponse struct {
+	// Token is the JWT used to authenticate subsequent requests.
+	Token string `json:"token"`
+}
+
+// hubPage is a single page of a Hub list response.
+type hubPage struct {
+	// Count of resources in the full list.
+	Count int `json:"count"`
+
+	// Next is the path to the next set of results.
+	Next string `json:"next"`
+
+	// Previous is the path to the previous set of results.
+	Previous string `json:"previous"`
+
+	// Results is the set of results in this page.
+	Results []interface{} `json:"results"`
+}
+
+// hubTag is a single tag as returned by the Hub tags API.
+type hubTag struct {
+	// Name of the tag.
+	Name string `json:"name"`
+
+	// Digest is the manifest list digest the tag resolves to.
+	Digest string `json:"digest"`
+
+	// LastUpdated is when the tag was last updated.
+	LastUpdated Time `json:"last_updated"`
+}
+
+// hubRepository is a single repository as returned by the Hub
+// repositories API.
+type hubRepository struct {
+	// Name of the repository, e.g. "nginx".
+	Name string `json:"name"`
+
+	// Namespace the repository belongs to, e.g. "library".
+	Namespace string `json:"namespace"`
+}
+
+// Hub is a Registry implementation backed by the Docker Hub specific API,
+// rather than the generic Distribution API.
+type Hub struct {
+	client   http.Client
+	username string
+	password string
+	token    string
+}
+
+// NewHub creates a new Hub registry client and authenticates with the
+// supplied credentials.
+func NewHub(username, password string) (*Hub, error) {
+	h := &Hub{
+		username: username,
+		password: password,
+	}
+
+	if err := h.login(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// login exchanges the configured credentials for a JWT.
+func (h *Hub) login() error {
+	data := map[string]string{
+		"username": h.username,
+		"password": h.password,
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to marshal authentication credentials: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hubBaseURL+"/users/login/", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("unable to create authentication request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to authenticate with registry: %w", err)
+	}
+
+	defer rsp.Body.Close()
+
+	body, err = ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read authentication response: %w", err)
+	}
+
+	authenticationResponse := &hubAuthenticationResponse{}
+	if err := json.Unmarshal(body, authenticationResponse); err != nil {
+		return fmt.Errorf("unable to unmarshal authentication response: %w", err)
+	}
+
+	h.token = authenticationResponse.Token
+
+	return nil
+}
+
+// do performs an authenticated request against the Hub API.
+func (h *Hub) do(method, url string, body []byte) (*http.Response, error) {
+	var reader *bytes.Buffer
+
+	if body != nil {
+		reader = bytes.NewBuffer(body)
+	} else {
+		reader = bytes.NewBuffer(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("JWT %s", h.token))
+
+	return h.client.Do(req)
+}
+
+// ListRepositories implements Registry. namespace defaults to the
+// authenticated user when empty, so whole-namespace scrubbing works
+// without also requiring -namespace.
+func (h *Hub) ListRepositories(namespace string) ([]string, error) {
+	if namespace == "" {
+		namespace = h.username
+	}
+
+	resultsUntyped := []interface{}{}
+
+	url := fmt.Sprintf("%s/repositories/%s/", hubBaseURL, namespace)
+
+	for url != "" {
+		rsp, err := h.do(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			return nil, newStatusError(rsp, fmt.Errorf("unexpected status code %d listing repositories under %q", rsp.StatusCode, namespace))
+		}
+
+		page := &hubPage{}
+		if err := json.Unmarshal(body, page); err != nil {
+			return nil, err
+		}
+
+		resultsUntyped = append(resultsUntyped, page.Results...)
+
+		url = page.Next
+	}
+
+	resultsRaw, err := json.Marshal(resultsUntyped)
+	if err != nil {
+		return nil, err
+	}
+
+	hubRepositories := []hubRepository{}
+	if err := json.Unmarshal(resultsRaw, &hubRepositories); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(hubRepositories))
+
+	for i, repo := range hubRepositories {
+		names[i] = fmt.Sprintf("%s/%s", repo.Namespace, repo.Name)
+	}
+
+	return names, nil
+}
+
+// ListTags implements Registry.
+func (h *Hub) ListTags(repository string) ([]Tag, error) {
+	resultsUntyped := []interface{}{}
+
+	url := fmt.Sprintf("%s/repositories/%s/tags", hubBaseURL, repository)
+
+	for url != "" {
+		rsp, err := h.do(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			return nil, newStatusError(rsp, fmt.Errorf("unexpected status code %d listing tags for %s", rsp.StatusCode, repository))
+		}
+
+		page := &hubPage{}
+		if err := json.Unmarshal(body, page); err != nil {
+			return nil, err
+		}
+
+		resultsUntyped = append(resultsUntyped, page.Results...)
+
+		url = page.Next
+	}
+
+	resultsRaw, err := json.Marshal(resultsUntyped)
+	if err != nil {
+		return nil, err
+	}
+
+	hubTags := []hubTag{}
+	if err := json.Unmarshal(resultsRaw, &hubTags); err != nil {
+		return nil, err
+	}
+
+	tags := make([]Tag, len(hubTags))
+
+	for i, t := range hubTags {
+		tags[i] = Tag{
+			Name:        t.Name,
+			Digest:      t.Digest,
+			LastUpdated: t.LastUpdated,
+		}
+	}
+
+	return tags, nil
+}
+
+// GetManifest implements Registry.
+func (h *Hub) GetManifest(repository, tag string) (string, error) {
+	url := fmt.Sprintf("%s/repositories/%s/tags/%s/", hubBaseURL, repository, tag)
+
+	rsp, err := h.do(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return "", newStatusError(rsp, fmt.Errorf("unexpected status code %d resolving manifest for %s:%s", rsp.StatusCode, repository, tag))
+	}
+
+	body, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	t := &hubTag{}
+	if err := json.Unmarshal(body, t); err != nil {
+		return "", err
+	}
+
+	return t.Digest, nil
+}
+
+// DeleteTag implements Registry.
+func (h *Hub) DeleteTag(repository, tag string) error {
+	url := fmt.Sprintf("%s/repositories/%s/tags/%s/", hubBaseURL, repository, tag)
+
+	rsp, err := h.do(http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusNoContent {
+		return newStatusError(rsp, fmt.Errorf("unexpected status code %d deleting tag %s", rsp.StatusCode, tag))
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import "fmt"
+
+// Backend identifies which Registry implementation to construct.
+type Backend string
+
+const (
+	// BackendHub talks to the Docker Hub specific API.
+	BackendHub Backend = "hub"
+
+	// BackendV2 talks to the Docker Registry HTTP API V2 / OCI
+	// Distribution Specification.
+	BackendV2 Backend = "v2"
+)
+
+// New constructs a Registry for the given backend. host is only required,
+// and only used, by BackendV2; Hub's endpoint is fixed.
+func New(backend Backend, host, username, password string) (Registry, error) {
+	switch backend {
+	case BackendHub:
+		return NewHub(username, password)
+	case BackendV2:
+		if host == "" {
+			return nil, fmt.Errorf("-host is required when -registry=%s", BackendV2)
+		}
+
+		return NewDistribution(host, username, password), nil
+	default:
+		return nil, fmt.Errorf("unknown registry backend %q", backend)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry abstracts over the different flavours of container
+// image registry API that hub-scrub needs to talk to, so the reaping
+// logic can be written once against an interface rather than against
+// Docker Hub specifically.
+package registry
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Time wraps time.Time so it can be decoded from the RFC3339Nano strings
+// registries encode timestamps as.
+type Time struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var s string
+
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	tt, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return err
+	}
+
+	t.Time = tt
+
+	return nil
+}
+
+// Tag represents a single tag within a repository, along with enough
+// metadata to decide whether it is a candidate for reaping.
+type Tag struct {
+	// Name of the tag.
+	Name string
+
+	// Digest is the manifest digest the tag currently resolves to.
+	Digest string
+
+	// LastUpdated is when the tag was last pushed, which we treat as
+	// when it was created.
+	LastUpdated Time
+}
+
+// Registry abstracts the operations hub-scrub needs from a container image
+// registry, so Docker Hub and OCI Distribution compliant registries (Harbor,
+// GHCR, ECR, self-hosted distribution, etc.) can be driven through a single
+// interface.
+type Registry interface {
+	// ListRepositories enumerates every repository hub-scrub is
+	// authorized to see. namespace scopes the search to a Hub
+	// account/organization; Distribution compliant registries expose a
+	// single global catalog and ignore it.
+	ListRepositories(namespace string) ([]string, error)
+
+	// ListTags returns every tag currently present in repository.
+	ListTags(repository string) ([]Tag, error)
+
+	// GetManifest resolves a tag to the digest of the manifest it points
+	// to.
+	GetManifest(repository, tag string) (string, error)
+
+	// DeleteTag removes a tag from repository.
+	DeleteTag(repository, tag string) error
+}
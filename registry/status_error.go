@@ -0,0 +1,74 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned by Registry operations that fail with an HTTP
+// status code, so callers can decide whether the failure is worth
+// retrying, e.g. 429 Too Many Requests or a 5xx server error.
+type StatusError struct {
+	// StatusCode is the HTTP status code the registry responded with.
+	StatusCode int
+
+	// RetryAfter is the duration the registry asked the caller to wait
+	// before retrying, parsed from a Retry-After header. Zero if the
+	// registry did not send one.
+	RetryAfter time.Duration
+
+	// Err describes the failure.
+	Err error
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap allows errors.Is/As to see through to Err.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError builds a StatusError from a failed response, picking up
+// any Retry-After header it carries.
+func newStatusError(rsp *http.Response, err error) *StatusError {
+	return &StatusError{
+		StatusCode: rsp.StatusCode,
+		RetryAfter: retryAfter(rsp),
+		Err:        err,
+	}
+}
+
+// retryAfter parses a Retry-After header expressed as a number of
+// seconds. HTTP also permits an HTTP-date form, which is rare in practice
+// for registries and is treated as absent.
+func retryAfter(rsp *http.Response) time.Duration {
+	v := rsp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewStatusErrorParsesRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "30")
+
+	rsp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}
+
+	err := newStatusError(rsp, errors.New("boom"))
+
+	if err.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, err.StatusCode)
+	}
+
+	if err.RetryAfter != 30*time.Second {
+		t.Errorf("expected RetryAfter of 30s, got %s", err.RetryAfter)
+	}
+}
+
+func TestNewStatusErrorMissingRetryAfter(t *testing.T) {
+	rsp := &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}}
+
+	err := newStatusError(rsp, errors.New("boom"))
+
+	if err.RetryAfter != 0 {
+		t.Errorf("expected no RetryAfter, got %s", err.RetryAfter)
+	}
+}
+
+func TestNewStatusErrorIgnoresInvalidRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "not-a-number")
+
+	rsp := &http.Response{StatusCode: http.StatusInternalServerError, Header: header}
+
+	err := newStatusError(rsp, errors.New("boom"))
+
+	if err.RetryAfter != 0 {
+		t.Errorf("expected an unparseable Retry-After to be ignored, got %s", err.RetryAfter)
+	}
+}
+
+func TestStatusErrorUnwrap(t *testing.T) {
+	inner := errors.New("boom")
+	err := &StatusError{Err: inner}
+
+	if !errors.Is(err, inner) {
+		t.Errorf("expected errors.Is to see through to the wrapped error")
+	}
+
+	if err.Error() != "boom" {
+		t.Errorf("expected Error() to delegate to the wrapped error, got %q", err.Error())
+	}
+}
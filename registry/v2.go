@@ -0,0 +1,233 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+
+	"github.com/spjmurray/hub-scrub/registry/auth"
+)
+
+// linkHeaderPattern extracts the URL from a Link header advertising the
+// next page of results, e.g. `<https://host/v2/_catalog?n=100&last=foo>; rel="next"`.
+var linkHeaderPattern = regexp.MustCompile(`^<([^>]+)>;\s*rel="next"$`)
+
+// v2TagList is the body of a GET /v2/<name>/tags/list response.
+type v2TagList struct {
+	// Name of the repository.
+	Name string `json:"name"`
+
+	// Tags present in the repository.
+	Tags []string `json:"tags"`
+}
+
+// v2Catalog is the body of a GET /v2/_catalog response.
+type v2Catalog struct {
+	// Repositories known to the registry.
+	Repositories []string `json:"repositories"`
+}
+
+// Distribution is a Registry implementation that speaks the Docker
+// Registry HTTP API V2 / OCI Distribution Specification, as implemented
+// by Harbor, GHCR, ECR and self-hosted `distribution`.
+type Distribution struct {
+	client http.Client
+
+	// host is the scheme and authority of the registry, e.g.
+	// "https://registry.example.com".
+	host string
+}
+
+// NewDistribution creates a new client for a Distribution compliant
+// registry rooted at host. Requests that fail with a 401 are retried once
+// the challenge in the response's WWW-Authenticate header has been
+// satisfied with username and password, supporting both Basic and Bearer
+// token schemes.
+func NewDistribution(host, username, password string) *Distribution {
+	return &Distribution{
+		host: host,
+		client: http.Client{
+			Transport: auth.NewAuthorizer(
+				auth.NewBasicHandler(username, password),
+				auth.NewBearerHandler(username, password),
+			),
+		},
+	}
+}
+
+// do performs a request against the registry.
+func (d *Distribution) do(method, url string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.client.Do(req)
+}
+
+// nextPage extracts the next page URL from a Link header, if present.
+func nextPage(rsp *http.Response) string {
+	for _, link := range rsp.Header.Values("Link") {
+		if match := linkHeaderPattern.FindStringSubmatch(link); match != nil {
+			return match[1]
+		}
+	}
+
+	return ""
+}
+
+// ListRepositories implements Registry. namespace is ignored: the
+// Distribution API exposes a single global catalog rather than
+// per-namespace listings.
+func (d *Distribution) ListRepositories(_ string) ([]string, error) {
+	names := []string{}
+
+	url := fmt.Sprintf("%s/v2/_catalog", d.host)
+
+	for url != "" {
+		rsp, err := d.do(http.MethodGet, url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			return nil, newStatusError(rsp, fmt.Errorf("unexpected status code %d listing catalog", rsp.StatusCode))
+		}
+
+		catalog := &v2Catalog{}
+		if err := json.Unmarshal(body, catalog); err != nil {
+			return nil, err
+		}
+
+		names = append(names, catalog.Repositories...)
+
+		url = nextPage(rsp)
+	}
+
+	return names, nil
+}
+
+// ListTags implements Registry.
+//
+// The Distribution API does not expose tag creation or push times, so the
+// returned tags carry no LastUpdated information; age based retention
+// rules have nothing to act on against this backend yet.
+func (d *Distribution) ListTags(repository string) ([]Tag, error) {
+	names := []string{}
+
+	url := fmt.Sprintf("%s/v2/%s/tags/list", d.host, repository)
+
+	for url != "" {
+		rsp, err := d.do(http.MethodGet, url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			return nil, newStatusError(rsp, fmt.Errorf("unexpected status code %d listing tags for %s", rsp.StatusCode, repository))
+		}
+
+		list := &v2TagList{}
+		if err := json.Unmarshal(body, list); err != nil {
+			return nil, err
+		}
+
+		names = append(names, list.Tags...)
+
+		url = nextPage(rsp)
+	}
+
+	tags := make([]Tag, len(names))
+
+	for i, name := range names {
+		tags[i] = Tag{Name: name}
+	}
+
+	return tags, nil
+}
+
+// GetManifest implements Registry.
+func (d *Distribution) GetManifest(repository, tag string) (string, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", d.host, repository, tag)
+
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return "", newStatusError(rsp, fmt.Errorf("unexpected status code %d resolving manifest for %s:%s", rsp.StatusCode, repository, tag))
+	}
+
+	digest := rsp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry did not return a Docker-Content-Digest header for %s:%s", repository, tag)
+	}
+
+	return digest, nil
+}
+
+// DeleteTag implements Registry.
+//
+// The Distribution API only supports deletion by digest, so the tag is
+// first resolved to the manifest it points at.
+func (d *Distribution) DeleteTag(repository, tag string) error {
+	digest, err := d.GetManifest(repository, tag)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", d.host, repository, digest)
+
+	rsp, err := d.do(http.MethodDelete, url)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusAccepted {
+		return newStatusError(rsp, fmt.Errorf("unexpected status code %d deleting tag %s", rsp.StatusCode, tag))
+	}
+
+	return nil
+}
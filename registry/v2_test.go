@@ -0,0 +1,120 @@
+// Copyright 2020 Simon Murray.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file  except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the  License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDistributionListRepositoriesFollowsLinkHeader(t *testing.T) {
+	var calls int
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls == 1 {
+			w.Header().Set("Link", `<`+srv.URL+`/v2/_catalog?last=nginx>; rel="next"`)
+			w.Write([]byte(`{"repositories":["library/nginx"]}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"repositories":["library/redis"]}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	d := NewDistribution(srv.URL, "", "")
+
+	repos, err := d.ListRepositories("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(repos) != 2 || repos[0] != "library/nginx" || repos[1] != "library/redis" {
+		t.Errorf("expected both pages of repositories, got %v", repos)
+	}
+}
+
+func TestDistributionGetManifestReturnsDigest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", "sha256:abc")
+	}))
+	t.Cleanup(srv.Close)
+
+	d := NewDistribution(srv.URL, "", "")
+
+	digest, err := d.GetManifest("library/nginx", "latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if digest != "sha256:abc" {
+		t.Errorf("expected digest sha256:abc, got %s", digest)
+	}
+}
+
+// TestDistributionGetManifestWrapsFailureStatus guards against a
+// regression where a failed manifest lookup was returned as a plain
+// error, hiding the HTTP status code reaper.delete needs to decide
+// whether the failure is retryable.
+func TestDistributionGetManifestWrapsFailureStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	d := NewDistribution(srv.URL, "", "")
+
+	_, err := d.GetManifest("library/nginx", "latest")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	statusErr := &StatusError{}
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *StatusError, got %T: %v", err, err)
+	}
+
+	if statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, statusErr.StatusCode)
+	}
+}
+
+func TestDistributionDeleteTagResolvesDigestFirst(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Docker-Content-Digest", "sha256:abc")
+		case http.MethodDelete:
+			if r.URL.Path != "/v2/library/nginx/manifests/sha256:abc" {
+				t.Errorf("expected delete by digest, got path %s", r.URL.Path)
+			}
+
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	d := NewDistribution(srv.URL, "", "")
+
+	if err := d.DeleteTag("library/nginx", "latest"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}